@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/liuhengloveyou/passport/models"
+	"github.com/liuhengloveyou/passport/session"
+	"github.com/liuhengloveyou/passport/session/csrf"
+
+	log "github.com/golang/glog"
+	gocommon "github.com/liuhengloveyou/go-common"
+	"github.com/liuhengloveyou/validator"
+)
+
+type Login struct {
+	Email    string `validate:"noneor,email"`
+	Phone    string `validate:"noneor,cellphone"`
+	Password string `validate:"nonone,min=6,max=24"`
+}
+
+func (p *Login) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	csrf.Middleware(http.HandlerFunc(p.serve)).ServeHTTP(w, r)
+}
+
+func (p *Login) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		p.doGet(w, r)
+	case "POST":
+		p.doPost(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+	return
+}
+
+// doGet 发一个 CSRF token 给页面，配合 csrf.Middleware 的 POST 校验用——
+// 没有这一步客户端永远拿不到合法 token，所有登录请求都会被 403 掉。
+func (p *Login) doGet(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.GetSession(w, r, "")
+	if err != nil {
+		gocommon.HttpErr(w, http.StatusInternalServerError, []byte(err.Error()))
+		log.Errorln("session.GetSession ERR: ", err)
+		return
+	}
+
+	gocommon.HttpErr(w, http.StatusOK, map[string]string{"csrf_token": csrf.Token(w, r, sess)})
+
+	return
+}
+
+func (p *Login) doPost(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		gocommon.HttpErr(w, http.StatusBadRequest, []byte(err.Error()))
+		log.Errorln("ioutil.ReadAll(r.Body) ERR: ", err)
+		return
+	}
+
+	login := &Login{}
+	err = json.Unmarshal(body, login)
+	if err != nil {
+		gocommon.HttpErr(w, http.StatusBadRequest, []byte(err.Error()))
+		log.Errorln("json.Unmarshal(body, login) ERR: ", err)
+		return
+	}
+
+	if err = validator.Validate(login); err != nil {
+		gocommon.HttpErr(w, http.StatusBadRequest, []byte(err.Error()))
+		log.Errorln(*login, err)
+		return
+	}
+
+	user := &models.User{Email: login.Email, Phone: login.Phone}
+	if err = user.Login(login.Password); err != nil {
+		gocommon.HttpErr(w, http.StatusUnauthorized, []byte(err.Error()))
+		log.Errorln(*login, err)
+		return
+	}
+
+	// 登录成功，换一个新的 sid 再记录用户身份，防止会话固定攻击
+	sess, err := session.SessionRegenerateId(w, r)
+	if err != nil {
+		gocommon.HttpErr(w, http.StatusInternalServerError, []byte(err.Error()))
+		log.Errorln("session.SessionRegenerateId ERR: ", err)
+		return
+	}
+	sess.Set("uid", user.Id)
+
+	gocommon.HttpErr(w, http.StatusOK, nil)
+
+	return
+}
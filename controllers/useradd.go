@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/liuhengloveyou/passport/models"
+	"github.com/liuhengloveyou/passport/session"
+	"github.com/liuhengloveyou/passport/session/csrf"
 
 	log "github.com/golang/glog"
 	gocommon "github.com/liuhengloveyou/go-common"
@@ -20,15 +22,37 @@ type UserAdd struct {
 }
 
 func (p *UserAdd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "POST" {
+	csrf.Middleware(http.HandlerFunc(p.serve)).ServeHTTP(w, r)
+}
+
+func (p *UserAdd) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		p.doGet(w, r)
+	case "POST":
 		p.doPost(w, r)
-	} else {
+	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 
 	return
 }
 
+// doGet 发一个 CSRF token 给页面，配合 csrf.Middleware 的 POST 校验用——
+// 没有这一步客户端永远拿不到合法 token，所有注册请求都会被 403 掉。
+func (p *UserAdd) doGet(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.GetSession(w, r, "")
+	if err != nil {
+		gocommon.HttpErr(w, http.StatusInternalServerError, []byte(err.Error()))
+		log.Errorln("session.GetSession ERR: ", err)
+		return
+	}
+
+	gocommon.HttpErr(w, http.StatusOK, map[string]string{"csrf_token": csrf.Token(w, r, sess)})
+
+	return
+}
+
 func (p *UserAdd) doPost(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -58,6 +82,11 @@ func (p *UserAdd) doPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 注册成功后存一条 flash 消息，配合注册页重定向到登录页时提示一次
+	if sess, sessErr := session.GetSession(w, r, ""); sessErr == nil {
+		session.AddFlash(sess, "account created")
+	}
+
 	gocommon.HttpErr(w, http.StatusOK, nil)
 
 	return
@@ -0,0 +1,135 @@
+// Package csrf 给 session.SessionStore 挂上按路径区分的 CSRF token：
+// Token 在首次访问某个路径时生成一个随机 token 存进会话，Middleware 在
+// POST/PUT/PATCH/DELETE 这类有副作用的请求上校验这个 token。
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/gob"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/liuhengloveyou/passport/session"
+)
+
+// sessionKey 是存放 token 的保留会话键。
+const sessionKey = "_csrf"
+
+const tokenLength = 32
+
+// MaxTokens 限制单个会话同时持有的按路径区分的 token 数量，超出时淘汰最早生成的那个。
+var MaxTokens = 50
+
+// HeaderName 和 FormField 分别是 Middleware 校验时认的请求头和表单字段名。
+const (
+	HeaderName = "X-CSRF-Token"
+	FormField  = "_csrf"
+)
+
+var unsafeMethods = map[string]bool{
+	"POST":   true,
+	"PUT":    true,
+	"PATCH":  true,
+	"DELETE": true,
+}
+
+type tokenEntry struct {
+	Path  string
+	Token string
+}
+
+// tokenStore 按生成顺序保存 path -> token，顺序本身就用来在超出 MaxTokens 时淘汰最老的一个。
+type tokenStore struct {
+	Entries []tokenEntry
+}
+
+func init() {
+	gob.Register(tokenStore{})
+}
+
+// Token 返回 sess 在当前请求路径下的 CSRF token，不存在就生成一个新的存回 sess。
+func Token(w http.ResponseWriter, r *http.Request, sess session.SessionStore) string {
+	store := loadStore(sess)
+
+	for _, e := range store.Entries {
+		if e.Path == r.URL.Path {
+			return e.Token
+		}
+	}
+
+	token := newToken()
+	store.Entries = append(store.Entries, tokenEntry{Path: r.URL.Path, Token: token})
+
+	for MaxTokens > 0 && len(store.Entries) > MaxTokens {
+		store.Entries = store.Entries[1:]
+	}
+
+	sess.Set(sessionKey, store)
+
+	return token
+}
+
+// Middleware 拦截 POST/PUT/PATCH/DELETE 请求，校验 X-CSRF-Token 请求头或 _csrf
+// 表单字段是否和会话里为当前路径记录的 token 一致，不一致返回 403。
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unsafeMethods[r.Method] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sess, err := session.GetSession(w, r, "")
+		if err != nil {
+			http.Error(w, "csrf: no session", http.StatusForbidden)
+			return
+		}
+
+		token := r.Header.Get(HeaderName)
+		if token == "" {
+			token = r.FormValue(FormField)
+		}
+
+		if !valid(sess, r.URL.Path, token) {
+			http.Error(w, "csrf: token mismatch", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func valid(sess session.SessionStore, path, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	store := loadStore(sess)
+	for _, e := range store.Entries {
+		if e.Path == path {
+			// 常数时间比较，避免逐字节比较的 token 校验留下计时侧信道。
+			return subtle.ConstantTimeCompare([]byte(e.Token), []byte(token)) == 1
+		}
+	}
+
+	return false
+}
+
+func loadStore(sess session.SessionStore) tokenStore {
+	if v := sess.Get(sessionKey); v != nil {
+		if store, ok := v.(tokenStore); ok {
+			return store
+		}
+	}
+
+	return tokenStore{}
+}
+
+func newToken() string {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		panic("csrf: " + err.Error())
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,122 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/session"
+	_ "github.com/liuhengloveyou/passport/session/providers/memory"
+)
+
+func newTestManager(t *testing.T) {
+	t.Helper()
+
+	session.InitDefaultSessionManager(map[string]interface{}{
+		"store_type":  "memory",
+		"cookie_name": "test_sid",
+		"idle_time":   3600,
+	})
+}
+
+func TestTokenIsStablePerPath(t *testing.T) {
+	newTestManager(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/a", nil)
+	sess, err := session.GetSession(w, r, "")
+	if err != nil {
+		t.Fatalf("GetSession ERR: %v", err)
+	}
+
+	first := Token(w, r, sess)
+	second := Token(w, r, sess)
+	if first != second {
+		t.Fatalf("Token changed on repeated calls for the same path: %q != %q", first, second)
+	}
+
+	r2 := httptest.NewRequest("GET", "/b", nil)
+	other := Token(w, r2, sess)
+	if other == first {
+		t.Fatalf("Token for a different path should not match")
+	}
+}
+
+func TestTokenEvictsOldestWhenOverCap(t *testing.T) {
+	newTestManager(t)
+
+	oldMax := MaxTokens
+	MaxTokens = 2
+	defer func() { MaxTokens = oldMax }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := session.GetSession(w, r, "")
+	if err != nil {
+		t.Fatalf("GetSession ERR: %v", err)
+	}
+
+	first := tokenForPath(w, sess, "/p1")
+	_ = tokenForPath(w, sess, "/p2")
+	_ = tokenForPath(w, sess, "/p3")
+
+	if valid(sess, "/p1", first) {
+		t.Fatalf("oldest token should have been evicted once MaxTokens was exceeded")
+	}
+}
+
+func tokenForPath(w http.ResponseWriter, sess session.SessionStore, path string) string {
+	r := httptest.NewRequest("GET", path, nil)
+	return Token(w, r, sess)
+}
+
+func TestMiddlewareRejectsMissingAndAcceptsValidToken(t *testing.T) {
+	newTestManager(t)
+
+	handlerCalled := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mw := Middleware(next)
+
+	// 先拿一个合法 token
+	w0 := httptest.NewRecorder()
+	r0 := httptest.NewRequest("GET", "/form", nil)
+	sess, err := session.GetSession(w0, r0, "")
+	if err != nil {
+		t.Fatalf("GetSession ERR: %v", err)
+	}
+	token := Token(w0, r0, sess)
+	cookies := w0.Result().Cookies()
+
+	// 没带 token 的 POST 应该被拒绝
+	wMiss := httptest.NewRecorder()
+	rMiss := httptest.NewRequest("POST", "/form", nil)
+	for _, c := range cookies {
+		rMiss.AddCookie(c)
+	}
+	mw.ServeHTTP(wMiss, rMiss)
+	if wMiss.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a token, got %d", wMiss.Code)
+	}
+	if handlerCalled {
+		t.Fatalf("next handler should not run when csrf validation fails")
+	}
+
+	// 带上正确 token 的 POST 应该放行
+	wOK := httptest.NewRecorder()
+	rOK := httptest.NewRequest("POST", "/form", nil)
+	for _, c := range cookies {
+		rOK.AddCookie(c)
+	}
+	rOK.Header.Set(HeaderName, token)
+	mw.ServeHTTP(wOK, rOK)
+	if wOK.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a valid token, got %d", wOK.Code)
+	}
+	if !handlerCalled {
+		t.Fatalf("next handler should have run when csrf validation passes")
+	}
+}
@@ -0,0 +1,43 @@
+package session
+
+import "encoding/gob"
+
+// flash 消息存在 SessionStore 自带的 Get/Set/Delete 上，不需要给每个 Provider
+// 单独加代码：AddFlash 往保留键里追加一条，Flashes 读出来就清空，配合
+// post-redirect-get 用，比如 UserAdd 成功后重定向，下一页读一次提示就消失了。
+// 具名分组存在 "_flash_<var>" 下，不传 var 就用默认的 "_flash"。
+const flashKey = "_flash"
+
+func init() {
+	gob.Register([]interface{}{})
+}
+
+func flashSessionKey(vars ...string) string {
+	if len(vars) > 0 && vars[0] != "" {
+		return flashKey + "_" + vars[0]
+	}
+
+	return flashKey
+}
+
+// AddFlash 往 sess 里追加一条 flash 消息，vars 的第一个值作为分组名，省略则用默认分组。
+func AddFlash(sess SessionStore, value interface{}, vars ...string) error {
+	key := flashSessionKey(vars...)
+
+	flashes, _ := sess.Get(key).([]interface{})
+	flashes = append(flashes, value)
+
+	return sess.Set(key, flashes)
+}
+
+// Flashes 取出并清空 sess 里某个分组的全部 flash 消息，读一次就没有了。
+func Flashes(sess SessionStore, vars ...string) []interface{} {
+	key := flashSessionKey(vars...)
+
+	flashes, _ := sess.Get(key).([]interface{})
+	if len(flashes) > 0 {
+		sess.Delete(key)
+	}
+
+	return flashes
+}
@@ -0,0 +1,57 @@
+package session
+
+import "testing"
+
+type fakeStore struct {
+	values map[interface{}]interface{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{values: make(map[interface{}]interface{})}
+}
+
+func (s *fakeStore) Id(string) string  { return "fake" }
+func (s *fakeStore) CreateTime() int64 { return 0 }
+func (s *fakeStore) Active(bool) int64 { return 0 }
+func (s *fakeStore) Keys() []interface{} {
+	keys := make([]interface{}, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	return keys
+}
+func (s *fakeStore) Get(key interface{}) interface{} { return s.values[key] }
+func (s *fakeStore) Set(key, val interface{}) error  { s.values[key] = val; return nil }
+func (s *fakeStore) Delete(key interface{}) error    { delete(s.values, key); return nil }
+func (s *fakeStore) Release()                        {}
+
+func TestFlashIsReturnedOnceAndCleared(t *testing.T) {
+	sess := newFakeStore()
+
+	if err := AddFlash(sess, "account created"); err != nil {
+		t.Fatalf("AddFlash ERR: %v", err)
+	}
+
+	flashes := Flashes(sess)
+	if len(flashes) != 1 || flashes[0] != "account created" {
+		t.Fatalf("Flashes = %v, want [\"account created\"]", flashes)
+	}
+
+	if flashes := Flashes(sess); len(flashes) != 0 {
+		t.Fatalf("Flashes on second read = %v, want empty", flashes)
+	}
+}
+
+func TestFlashGroupsAreIndependent(t *testing.T) {
+	sess := newFakeStore()
+
+	AddFlash(sess, "default group")
+	AddFlash(sess, "named group", "notice")
+
+	if got := Flashes(sess); len(got) != 1 || got[0] != "default group" {
+		t.Fatalf("default group Flashes = %v", got)
+	}
+	if got := Flashes(sess, "notice"); len(got) != 1 || got[0] != "named group" {
+		t.Fatalf("named group Flashes = %v", got)
+	}
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"fmt"
+)
+
+// Provider 是会话后端存储的抽象，负责某一类会话的创建、读取、销毁和过期回收，
+// 取代了早期只能构造单个 SessionStore 的 SessionStoreType。
+// SessionManager 根据 StoreType 选用已注册的 Provider 实现，
+// 从而让会话可以在进程重启后存活，也可以被多个 passport 实例共享（如 redis、file）。
+type Provider interface {
+	SessionInit(sid string) (SessionStore, error)               // 创建一个新会话
+	SessionRead(sid string) (SessionStore, error)               // 读取会话，不存在时新建一个（同 SessionInit）
+	SessionExist(sid string) (bool, error)                      // 判断会话是否存在
+	SessionRegenerate(oldsid, sid string) (SessionStore, error) // 将 oldsid 的内容迁移到新的 sid 上
+	SessionDestroy(sid string) error                            // 销毁一个会话
+	SessionAll() int                                            // 当前存活的会话数
+	SessionGC(maxLifetime int64)                                // 回收超过 maxLifetime 秒未活动的会话
+}
+
+// PrepireReleaseSetter 是可选接口，Provider 如果希望在 gc 回收会话时
+// 回调通知上层（见 SessionManager.SetPrepireRelease），可以实现它。
+type PrepireReleaseSetter interface {
+	SetPrepireRelease(PrepireReleaseFunc)
+}
+
+// SidValidator 是可选接口。SessionManager 默认只接受 sessionId() 那种固定
+// 长度的十六进制字符串，其余一律当成非法格式拒绝，不往 provider 传——这是
+// 为了防止客户端在 cookie 里塞一个路径穿越字符串，被 file provider 之类直接
+// 拼进文件路径。如果 Provider 的 sid 本身就不是这种格式（比如 cookie store
+// 拿 sid 当整个编码后的会话内容），应该实现这个接口提供自己的校验规则。
+type SidValidator interface {
+	ValidSid(sid string) bool
+}
+
+// ProviderCloser 是可选接口，Provider 如果持有需要主动释放的资源
+// （比如 memory provider 常驻的 GC 后台 goroutine），应该实现它；
+// SessionManager.Destroy 会在销毁时调用一次，防止重建 SessionManager
+// （如 InitDefaultSessionManager 重新配置）时旧 provider 一直泄漏下去。
+type ProviderCloser interface {
+	Close()
+}
+
+// ProviderFactory 根据配置构造一个 Provider 实例，用法与以前的 SessionStoreType 一致。
+type ProviderFactory func(config interface{}) (Provider, error)
+
+var providers = make(map[string]ProviderFactory)
+
+// RegisterProvider 注册一个 Provider 工厂，name 对应 SessionManager.StoreType。
+func RegisterProvider(name string, factory ProviderFactory) {
+	if factory == nil {
+		panic("Register Provider nil")
+	}
+
+	if _, dup := providers[name]; dup {
+		panic("Register Provider duplicate for " + name)
+	}
+
+	providers[name] = factory
+}
+
+func newProvider(typeName string, config interface{}) (Provider, error) {
+	if factory, ok := providers[typeName]; ok {
+		return factory(config)
+	}
+
+	return nil, fmt.Errorf("No Provider types " + typeName)
+}
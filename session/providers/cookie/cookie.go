@@ -0,0 +1,292 @@
+// Package cookie 是 session.Provider 的客户端实现：会话内容整体签名加密后存在
+// cookie 值里，sid 本身就是编码后的内容，服务端不保存任何状态，
+// 适合无状态部署在负载均衡后面、不想用粘性会话或共享 redis 的场景。
+package cookie
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/liuhengloveyou/passport/session"
+	"github.com/liuhengloveyou/passport/session/securecookie"
+)
+
+func init() {
+	session.RegisterProvider("cookie", newProvider)
+}
+
+// maxCookieSize 是浏览器普遍支持的单个 cookie 大小上限。
+const maxCookieSize = 4096
+
+// KeyPair 是一对签名/加密密钥，BlockKey 留空表示不加密、只签名。
+type KeyPair struct {
+	HashKey  string `json:"hash_key"`
+	BlockKey string `json:"block_key"`
+}
+
+// Config 对应 SessionManager.StoreConfig。KeyPairs 的第一对用于编码，
+// 其余的只在解码时按顺序尝试，用来支持密钥轮换。
+type Config struct {
+	Name     string    `json:"name"` // 参与签名的名字，默认 "passport_session"
+	KeyPairs []KeyPair `json:"key_pairs"`
+	MaxAge   int64     `json:"max_age"` // 秒，0 表示用 securecookie 的默认值
+}
+
+type payload struct {
+	CreateTime int64
+	Active     int64
+	Values     map[interface{}]interface{}
+}
+
+type sessionStore struct {
+	sid       string // cookie 当前的编码值，即会话的全部内容
+	payload   payload
+	provider  *Provider
+	w         http.ResponseWriter
+	cookieTpl *http.Cookie
+	lock      sync.RWMutex
+}
+
+func (s *sessionStore) Id(sid string) string {
+	if sid != "" {
+		s.sid = sid
+	}
+
+	return s.sid
+}
+
+func (s *sessionStore) CreateTime() int64 {
+	return s.payload.CreateTime
+}
+
+func (s *sessionStore) Active(set bool) int64 {
+	if set {
+		s.lock.Lock()
+		s.payload.Active = time.Now().Unix()
+		s.persistLocked()
+		s.lock.Unlock()
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Active
+}
+
+func (s *sessionStore) Keys() []interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]interface{}, 0, len(s.payload.Values))
+	for k := range s.payload.Values {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func (s *sessionStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Values[key]
+}
+
+func (s *sessionStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.payload.Values[key] = val
+	return s.persistLocked()
+}
+
+func (s *sessionStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.payload.Values, key)
+	return s.persistLocked()
+}
+
+func (s *sessionStore) Release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.w == nil || s.cookieTpl == nil {
+		return
+	}
+
+	expired := *s.cookieTpl
+	expired.Value = ""
+	expired.MaxAge = -1
+	expired.Expires = time.Now()
+	http.SetCookie(s.w, &expired)
+}
+
+// BindWriter 实现 session.WriterBinder：cookie store 需要在内容变化时
+// 主动重新下发 Set-Cookie，而不是等 SessionManager 统一写一次。
+func (s *sessionStore) BindWriter(w http.ResponseWriter, tpl *http.Cookie) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.w = w
+	s.cookieTpl = tpl
+}
+
+// persistLocked 重新编码当前内容并（如果已经绑定了 ResponseWriter）立即下发新的
+// Set-Cookie，调用者需持有 s.lock。
+func (s *sessionStore) persistLocked() error {
+	encoded, err := s.provider.encode(s.payload)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > maxCookieSize {
+		return fmt.Errorf("cookie session value exceeds %d bytes limit", maxCookieSize)
+	}
+
+	s.sid = encoded
+
+	if s.w != nil && s.cookieTpl != nil {
+		c := *s.cookieTpl
+		c.Value = url.QueryEscape(encoded)
+		http.SetCookie(s.w, &c)
+	}
+
+	return nil
+}
+
+type Provider struct {
+	name   string
+	codecs []*securecookie.Codec
+}
+
+func newProvider(config interface{}) (session.Provider, error) {
+	conf := &Config{}
+
+	if config != nil {
+		b, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(conf.KeyPairs) == 0 {
+		return nil, fmt.Errorf("cookie provider requires at least one key_pairs entry")
+	}
+	if conf.Name == "" {
+		conf.Name = "passport_session"
+	}
+
+	codecs := make([]*securecookie.Codec, 0, len(conf.KeyPairs))
+	for _, kp := range conf.KeyPairs {
+		c := securecookie.New([]byte(kp.HashKey), []byte(kp.BlockKey))
+		if conf.MaxAge > 0 {
+			c.MaxAge(conf.MaxAge)
+		}
+		codecs = append(codecs, c)
+	}
+
+	return &Provider{name: conf.Name, codecs: codecs}, nil
+}
+
+// ValidSid 实现 session.SidValidator：cookie store 的 sid 就是编码后的会话
+// 内容本身，不是 SessionManager 生成的固定长度十六进制串，接受任意格式——
+// 伪造或篡改过的值会在 decode 里因为签名/解密失败而拒绝，不需要在这里重复校验。
+func (p *Provider) ValidSid(sid string) bool {
+	return true
+}
+
+func (p *Provider) encode(pl payload) (string, error) {
+	return securecookie.EncodeMulti(p.name, pl, p.codecs...)
+}
+
+func (p *Provider) decode(sid string) (payload, error) {
+	var pl payload
+	err := securecookie.DecodeMulti(p.name, sid, &pl, p.codecs...)
+	return pl, err
+}
+
+func (p *Provider) SessionInit(sid string) (session.SessionStore, error) {
+	now := time.Now().Unix()
+
+	store := &sessionStore{
+		provider: p,
+		payload: payload{
+			CreateTime: now,
+			Active:     now,
+			Values:     make(map[interface{}]interface{}),
+		},
+	}
+
+	store.lock.Lock()
+	err := store.persistLocked()
+	store.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionRead(sid string) (session.SessionStore, error) {
+	pl, err := p.decode(sid)
+	if err != nil {
+		return p.SessionInit(sid)
+	}
+
+	return &sessionStore{sid: sid, provider: p, payload: pl}, nil
+}
+
+func (p *Provider) SessionExist(sid string) (bool, error) {
+	if sid == "" {
+		return false, nil
+	}
+
+	_, err := p.decode(sid)
+	return err == nil, nil
+}
+
+// SessionRegenerate 对 cookie store 来说没有"旧 sid 位置"可言，
+// 只要内容能从 oldsid 解出来，就原样搬到一份新的 SessionStore 上，
+// 并立即调用 persistLocked 重新编码，得到新的 sid；传入的 sid 不会被使用。
+func (p *Provider) SessionRegenerate(oldsid, sid string) (session.SessionStore, error) {
+	pl, err := p.decode(oldsid)
+	if err != nil {
+		return p.SessionInit(sid)
+	}
+
+	store := &sessionStore{provider: p, payload: pl}
+
+	store.lock.Lock()
+	err = store.persistLocked()
+	store.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// SessionDestroy 对 cookie store 是空操作，provider 本身不持有任何状态可删；
+// 浏览器端的清除靠下发一个已过期的同名 cookie（SessionManager.SessionDestroy
+// 会这样做，绑定过 ResponseWriter 的 sessionStore.Release() 也会）。
+func (p *Provider) SessionDestroy(sid string) error {
+	return nil
+}
+
+// SessionAll 无法统计，cookie store 不在服务端保存会话列表。
+func (p *Provider) SessionAll() int {
+	return -1
+}
+
+// SessionGC 是空操作：每个 cookie 的有效期已经由 securecookie 的 MaxAge 和
+// Set-Cookie 的 MaxAge 属性控制，浏览器会自己丢弃过期的值。
+func (p *Provider) SessionGC(maxLifetime int64) {
+}
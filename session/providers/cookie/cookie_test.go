@@ -0,0 +1,94 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	p, err := newProvider(&Config{
+		KeyPairs: []KeyPair{{HashKey: "hash-key-0123456789", BlockKey: "0123456789abcdef"}},
+	})
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+
+	return p.(*Provider)
+}
+
+func TestSessionInitReadRoundTrip(t *testing.T) {
+	p := newTestProvider(t)
+
+	store, err := p.SessionInit("")
+	if err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	if err := store.Set("k", "v"); err != nil {
+		t.Fatalf("Set ERR: %v", err)
+	}
+
+	sid := store.Id("")
+
+	exist, err := p.SessionExist(sid)
+	if err != nil || !exist {
+		t.Fatalf("SessionExist = %v, %v, want true, nil", exist, err)
+	}
+
+	read, err := p.SessionRead(sid)
+	if err != nil {
+		t.Fatalf("SessionRead ERR: %v", err)
+	}
+	if got := read.Get("k"); got != "v" {
+		t.Fatalf("Get(k) = %v, want v", got)
+	}
+}
+
+func TestSessionExistRejectsGarbage(t *testing.T) {
+	p := newTestProvider(t)
+
+	exist, err := p.SessionExist("not-a-real-cookie-value")
+	if err != nil {
+		t.Fatalf("SessionExist ERR: %v", err)
+	}
+	if exist {
+		t.Fatalf("SessionExist = true for garbage input")
+	}
+}
+
+func TestSetRejectsOversizedValue(t *testing.T) {
+	p := newTestProvider(t)
+
+	store, err := p.SessionInit("")
+	if err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	if err := store.Set("big", strings.Repeat("x", maxCookieSize*2)); err == nil {
+		t.Fatalf("Set with an oversized value did not return an error")
+	}
+}
+
+func TestBindWriterRefreshesCookieOnMutation(t *testing.T) {
+	p := newTestProvider(t)
+
+	store, err := p.SessionInit("")
+	if err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	store.(*sessionStore).BindWriter(w, &http.Cookie{Name: "passport_session", Path: "/"})
+
+	if err := store.Set("k", "v"); err != nil {
+		t.Fatalf("Set ERR: %v", err)
+	}
+
+	if len(w.Result().Cookies()) == 0 {
+		t.Fatalf("expected a Set-Cookie header after mutating a bound session")
+	}
+}
@@ -0,0 +1,301 @@
+// Package file 是 session.Provider 的文件实现，会话数据以 gob 编码保存在磁盘上，
+// 按 sid 前两段十六进制字符做两级目录分片，避免单目录文件数过多。
+// 用于多个 passport 进程共享同一块磁盘（如 NFS）或单进程重启后恢复会话的场景。
+package file
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/liuhengloveyou/passport/session"
+)
+
+func init() {
+	session.RegisterProvider("file", newProvider)
+}
+
+// Config 对应 SessionManager.StoreConfig。
+type Config struct {
+	SavePath string `json:"save_path"` // 会话文件存放的根目录，不填则用系统临时目录
+}
+
+// payload 是落盘的内容，与 sessionStore 分离以便用 gob 编解码。
+type payload struct {
+	CreateTime int64
+	Active     int64
+	Values     map[interface{}]interface{}
+}
+
+type sessionStore struct {
+	sid      string
+	savePath string
+	payload  payload
+	lock     sync.RWMutex
+}
+
+func (s *sessionStore) Id(sid string) string {
+	if sid != "" {
+		s.sid = sid
+	}
+
+	return s.sid
+}
+
+func (s *sessionStore) CreateTime() int64 {
+	return s.payload.CreateTime
+}
+
+func (s *sessionStore) Active(set bool) int64 {
+	if set {
+		s.lock.Lock()
+		s.payload.Active = time.Now().Unix()
+		s.persistLocked()
+		s.lock.Unlock()
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Active
+}
+
+func (s *sessionStore) Keys() []interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]interface{}, 0, len(s.payload.Values))
+	for k := range s.payload.Values {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func (s *sessionStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Values[key]
+}
+
+func (s *sessionStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.payload.Values[key] = val
+	return s.persistLocked()
+}
+
+func (s *sessionStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.payload.Values, key)
+	return s.persistLocked()
+}
+
+func (s *sessionStore) Release() {
+}
+
+// persistLocked 把当前内容写回 sid 对应的文件，调用者需持有 s.lock。
+func (s *sessionStore) persistLocked() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.payload); err != nil {
+		return err
+	}
+
+	path := sessionFilePath(s.savePath, s.sid)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// sessionFilePath 用 sid 的前两段十六进制字符做两级目录分片，
+// 避免会话量大时单个目录下文件过多。sid 过短（尤其是空字符串，
+// SessionRegenerate 在没有旧 cookie 时会传入）不够分片，归到固定的
+// "_short" 子目录下，以 sid 本身为文件名；空字符串则换成占位文件名
+// "_empty"——空字符串会被 filepath.Join 吃掉，让路径退化成目录本身，
+// 把会话目录整个当成会话文件来 stat/read。
+func sessionFilePath(savePath, sid string) string {
+	if len(sid) < 4 {
+		name := sid
+		if name == "" {
+			name = "_empty"
+		}
+		return filepath.Join(savePath, "_short", name)
+	}
+
+	return filepath.Join(savePath, sid[0:2], sid[2:4], sid)
+}
+
+type Provider struct {
+	savePath string
+}
+
+func newProvider(config interface{}) (session.Provider, error) {
+	conf := &Config{}
+
+	if config != nil {
+		b, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.SavePath == "" {
+		conf.SavePath = filepath.Join(os.TempDir(), "passport_sessions")
+	}
+
+	if err := os.MkdirAll(conf.SavePath, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Provider{savePath: conf.SavePath}, nil
+}
+
+func (p *Provider) load(sid string) (*sessionStore, error) {
+	b, err := ioutil.ReadFile(sessionFilePath(p.savePath, sid))
+	if err != nil {
+		return nil, err
+	}
+
+	store := &sessionStore{sid: sid, savePath: p.savePath}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&store.payload); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionInit(sid string) (session.SessionStore, error) {
+	now := time.Now().Unix()
+
+	store := &sessionStore{
+		sid:      sid,
+		savePath: p.savePath,
+		payload: payload{
+			CreateTime: now,
+			Active:     now,
+			Values:     make(map[interface{}]interface{}),
+		},
+	}
+
+	if err := store.persistLocked(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionRead(sid string) (session.SessionStore, error) {
+	store, err := p.load(sid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p.SessionInit(sid)
+		}
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionExist(sid string) (bool, error) {
+	_, err := os.Stat(sessionFilePath(p.savePath, sid))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// SessionRegenerate 原子地创建新 sid 对应的文件、拷贝旧会话内容，再删除旧文件。
+func (p *Provider) SessionRegenerate(oldsid, sid string) (session.SessionStore, error) {
+	old, err := p.load(oldsid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p.SessionInit(sid)
+		}
+		return nil, err
+	}
+
+	newPath := sessionFilePath(p.savePath, sid)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp := newPath + ".tmp"
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(old.payload); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, newPath); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	if err := os.Remove(sessionFilePath(p.savePath, oldsid)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &sessionStore{sid: sid, savePath: p.savePath, payload: old.payload}, nil
+}
+
+func (p *Provider) SessionDestroy(sid string) error {
+	err := os.Remove(sessionFilePath(p.savePath, sid))
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (p *Provider) SessionAll() int {
+	count := 0
+
+	filepath.Walk(p.savePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil || info.IsDir() {
+			return nil
+		}
+		count++
+		return nil
+	})
+
+	return count
+}
+
+// SessionGC 遍历 savePath 下的所有会话文件，把 mtime 早于 maxLifetime 的删掉。
+func (p *Provider) SessionGC(maxLifetime int64) {
+	deadline := time.Now().Add(-time.Duration(maxLifetime) * time.Second)
+
+	filepath.Walk(p.savePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil || info.IsDir() {
+			return nil
+		}
+
+		if info.ModTime().Before(deadline) {
+			if rmErr := os.Remove(path); rmErr != nil {
+				return fmt.Errorf("SessionGC remove %s: %w", path, rmErr)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/liuhengloveyou/passport/session/sessiontest"
+)
+
+func TestFileProvider(t *testing.T) {
+	p, err := newProvider(&Config{SavePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+
+	sessiontest.RunProviderSuite(t, p)
+}
+
+// 回归测试：SessionRegenerateId 在没有旧 cookie 时会拿空字符串当 oldsid，
+// 之前 sessionFilePath(savePath, "") 会退化成 savePath 本身，导致 SessionExist
+// 误判为 true，SessionRead/SessionRegenerate 拿目录当文件读直接报错。
+func TestEmptySidDoesNotAliasSavePath(t *testing.T) {
+	raw, err := newProvider(&Config{SavePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	p := raw.(*Provider)
+
+	if exist, err := p.SessionExist(""); err != nil || exist {
+		t.Fatalf("SessionExist(\"\") = %v, %v; want false, nil", exist, err)
+	}
+
+	if _, err := p.SessionRead(""); err != nil {
+		t.Fatalf("SessionRead(\"\") ERR: %v", err)
+	}
+
+	store, err := p.SessionRegenerate("", "newsid")
+	if err != nil {
+		t.Fatalf("SessionRegenerate(\"\", newsid) ERR: %v", err)
+	}
+	if got := store.Id(""); got != "newsid" {
+		t.Fatalf("SessionRegenerate(\"\", newsid) = %q, want newsid", got)
+	}
+}
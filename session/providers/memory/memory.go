@@ -0,0 +1,373 @@
+// Package memory 是 session.Provider 的内存实现，进程重启或多实例部署时不会
+// 保留或共享会话。过期回收用一个 container/heap 的最小堆按到期时间排序，
+// 一个后台 goroutine 睡到堆顶到期再批量回收，不依赖"访问顺序即过期顺序"这种
+// 只有 IdleTime 恒定才成立的假设。
+package memory
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+
+	"github.com/liuhengloveyou/passport/session"
+)
+
+func init() {
+	session.RegisterProvider("memory", newProvider)
+}
+
+type sessionStore struct {
+	sid        string
+	createTime int64
+	active     int64
+	values     map[interface{}]interface{}
+	provider   *Provider
+	lock       sync.RWMutex
+}
+
+func newSessionStore(sid string, provider *Provider) *sessionStore {
+	now := time.Now().Unix()
+
+	return &sessionStore{
+		sid:        sid,
+		createTime: now,
+		active:     now,
+		values:     make(map[interface{}]interface{}),
+		provider:   provider,
+	}
+}
+
+func (s *sessionStore) Id(sid string) string {
+	if sid != "" {
+		s.sid = sid
+	}
+
+	return s.sid
+}
+
+func (s *sessionStore) CreateTime() int64 {
+	return s.createTime
+}
+
+func (s *sessionStore) Active(set bool) int64 {
+	if set {
+		s.lock.Lock()
+		s.active = time.Now().Unix()
+		active := s.active
+		s.lock.Unlock()
+
+		if s.provider != nil {
+			s.provider.reschedule(s.sid, active)
+		}
+
+		return active
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.active
+}
+
+func (s *sessionStore) Keys() []interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]interface{}, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func (s *sessionStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.values[key]
+}
+
+func (s *sessionStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.values[key] = val
+	return nil
+}
+
+func (s *sessionStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.values, key)
+	return nil
+}
+
+func (s *sessionStore) Release() {
+}
+
+// heapEntry 是堆里的一条 (sid, 到期时间) 记录。同一个 sid 可能同时有好几条
+// 过期的旧记录留在堆里（每次 Active(true) 都会推一条新的），回收时按
+// entry.expiry 是否还等于该会话当前的真实到期时间来判断是不是陈旧记录。
+type heapEntry struct {
+	sid    string
+	expiry int64
+}
+
+type expiryHeap []*heapEntry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].expiry < h[j].expiry }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(*heapEntry)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// Provider 把会话存在 map 里，用一个 expiryHeap 记录每个会话的到期时间。
+type Provider struct {
+	sessions       map[string]*sessionStore
+	heap           expiryHeap
+	maxLifetime    int64
+	prepireRelease session.PrepireReleaseFunc
+	lock           sync.Mutex
+	wake           chan struct{}
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+func newProvider(config interface{}) (session.Provider, error) {
+	p := &Provider{
+		sessions: make(map[string]*sessionStore),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+// Close 停掉后台的 run() goroutine，SessionManager.Destroy 会调用它。
+// 没有它的话，每次 InitDefaultSessionManager 重新配置都会多泄漏一个
+// 永远醒着、各自持有一份 sessions/heap 的 goroutine。
+func (p *Provider) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// reschedule 在会话被访问后把它的新到期时间推进堆里，并叫醒后台 goroutine
+// 重新计算该睡多久——不需要从堆里删掉旧记录，回收时会按过期时间是否依然
+// 有效来跳过陈旧记录。
+func (p *Provider) reschedule(sid string, active int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.maxLifetime <= 0 {
+		return
+	}
+	if _, ok := p.sessions[sid]; !ok {
+		return
+	}
+
+	heap.Push(&p.heap, &heapEntry{sid: sid, expiry: active + p.maxLifetime})
+	p.wakeLocked()
+}
+
+func (p *Provider) wakeLocked() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Provider) SessionInit(sid string) (session.SessionStore, error) {
+	store := newSessionStore(sid, p)
+
+	p.lock.Lock()
+	p.sessions[sid] = store
+	p.lock.Unlock()
+
+	p.reschedule(sid, store.createTime)
+
+	return store, nil
+}
+
+func (p *Provider) SessionRead(sid string) (session.SessionStore, error) {
+	p.lock.Lock()
+	store, ok := p.sessions[sid]
+	p.lock.Unlock()
+
+	if !ok {
+		return p.SessionInit(sid)
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionExist(sid string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, ok := p.sessions[sid]
+	return ok, nil
+}
+
+func (p *Provider) SessionRegenerate(oldsid, sid string) (session.SessionStore, error) {
+	p.lock.Lock()
+	old, ok := p.sessions[oldsid]
+	if !ok {
+		store := newSessionStore(sid, p)
+		p.sessions[sid] = store
+		p.lock.Unlock()
+
+		p.reschedule(sid, store.createTime)
+		return store, nil
+	}
+
+	old.lock.Lock()
+	store := &sessionStore{
+		sid:        sid,
+		createTime: old.createTime,
+		active:     time.Now().Unix(),
+		values:     old.values,
+		provider:   p,
+	}
+	old.lock.Unlock()
+
+	delete(p.sessions, oldsid)
+	p.sessions[sid] = store
+	p.lock.Unlock()
+
+	p.reschedule(sid, store.active)
+
+	return store, nil
+}
+
+func (p *Provider) SessionDestroy(sid string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	store, ok := p.sessions[sid]
+	if !ok {
+		return nil
+	}
+
+	store.Release()
+	delete(p.sessions, sid)
+
+	return nil
+}
+
+func (p *Provider) SessionAll() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.sessions)
+}
+
+// SessionGC 只是把 maxLifetime 缓存下来供 reschedule 用，真正的回收在后台的
+// run() goroutine 里持续进行，不需要 SessionManager 按固定周期触发——调度堆
+// 记录的活只在 SessionInit/SessionRegenerate/Active(true) 这几个会话真正被
+// 创建或访问的时刻做，SessionGC 本身不会再去扫一遍 p.sessions，否则就是把
+// 这个请求本来要去掉的 O(n) 全表扫描换了个地方重新引入。
+// NewSessionManager 在创建任何会话之前就会同步调用一次 gc()（进而调用到这
+// 里），所以 maxLifetime 在第一个会话创建时已经是正确值，不存在"会话建立时
+// maxLifetime 还是 0，错过调度"的问题。
+func (p *Provider) SessionGC(maxLifetime int64) {
+	p.lock.Lock()
+	p.maxLifetime = maxLifetime
+	p.wakeLocked()
+	p.lock.Unlock()
+}
+
+func (p *Provider) SetPrepireRelease(pf session.PrepireReleaseFunc) {
+	p.lock.Lock()
+	p.prepireRelease = pf
+	p.lock.Unlock()
+}
+
+// run 是唯一的后台回收 goroutine：睡到堆顶到期，再批量回收，每条记录只在
+// 真正删除那一刻短暂持锁，不会像之前那样在整个释放期间一直持有写锁。
+// Close 被调用后通过 p.done 退出，不会无限期挂在后台。
+func (p *Provider) run() {
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		p.lock.Lock()
+		sleep := p.nextSleepLocked()
+		p.lock.Unlock()
+
+		if sleep > 0 {
+			select {
+			case <-p.done:
+				return
+			case <-p.wake:
+			case <-time.After(sleep):
+			}
+			continue
+		}
+
+		p.releaseExpiredBatch()
+	}
+}
+
+func (p *Provider) nextSleepLocked() time.Duration {
+	if len(p.heap) == 0 || p.maxLifetime <= 0 {
+		return time.Hour // 没有待过期的会话，睡久一点，靠 wake 及时叫醒
+	}
+
+	now := time.Now().Unix()
+	if p.heap[0].expiry <= now {
+		return 0
+	}
+
+	return time.Duration(p.heap[0].expiry-now) * time.Second
+}
+
+func (p *Provider) releaseExpiredBatch() {
+	now := time.Now().Unix()
+
+	for {
+		p.lock.Lock()
+		if len(p.heap) == 0 || p.heap[0].expiry > now {
+			p.lock.Unlock()
+			return
+		}
+
+		entry := heap.Pop(&p.heap).(*heapEntry)
+		store, ok := p.sessions[entry.sid]
+		if !ok {
+			p.lock.Unlock()
+			continue // 会话已经被销毁，这条过期记录直接丢弃
+		}
+
+		if store.Active(false)+p.maxLifetime != entry.expiry {
+			p.lock.Unlock()
+			continue // 会话在这条记录入堆之后又被访问过，是条陈旧记录
+		}
+
+		delete(p.sessions, entry.sid)
+		prepireRelease := p.prepireRelease
+		p.lock.Unlock()
+
+		log.Warningln("sessionrelease:", entry.sid)
+		if prepireRelease != nil {
+			prepireRelease(store)
+		}
+		store.Release()
+	}
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/session/sessiontest"
+)
+
+func TestMemoryProvider(t *testing.T) {
+	p, err := newProvider(nil)
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	defer p.(*Provider).Close()
+
+	sessiontest.RunProviderSuite(t, p)
+}
+
+// 回归测试：newProvider 起的后台 run() goroutine 之前没有任何退出办法，
+// 每次 SessionManager 被重建（如 InitDefaultSessionManager 重新配置）都会
+// 多泄漏一个。Close 之后 p.done 应该被关闭，run() 正是靠它退出的。
+func TestCloseStopsBackgroundGoroutine(t *testing.T) {
+	raw, err := newProvider(nil)
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	p := raw.(*Provider)
+
+	p.Close()
+
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		t.Fatalf("Close did not signal run() to stop")
+	}
+
+	// Close 应该可以安全地重复调用。
+	p.Close()
+}
+
+func TestSessionGCReapsExpiredSession(t *testing.T) {
+	raw, err := newProvider(nil)
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	p := raw.(*Provider)
+	defer p.Close()
+
+	// 和真实的 NewSessionManager 一样，先调用一次 SessionGC 把 maxLifetime
+	// 灌进去，再创建会话——SessionGC 本身不再扫 p.sessions 补调度记录。
+	p.SessionGC(1)
+	if _, err := p.SessionInit("expire-me"); err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if exist, _ := p.SessionExist("expire-me"); !exist {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session was not reaped within the maxLifetime")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSessionGCDoesNotReapRecentlyActiveSession(t *testing.T) {
+	raw, err := newProvider(nil)
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	p := raw.(*Provider)
+	defer p.Close()
+
+	p.SessionGC(2)
+	store, err := p.SessionInit("keep-me")
+	if err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	// 在到期前不断续活，GC 不应该把它回收掉。
+	stop := time.Now().Add(3 * time.Second)
+	for time.Now().Before(stop) {
+		time.Sleep(200 * time.Millisecond)
+		store.Active(true)
+	}
+
+	if exist, _ := p.SessionExist("keep-me"); !exist {
+		t.Fatalf("session that was kept active should not have been reaped")
+	}
+}
+
+// 回归测试：SessionGC 曾经每次被调用都会遍历一遍 p.sessions 重新入堆，
+// 在活跃会话很多、IdleTime 周期性触发 gc 的场景下，这就是请求要去掉的那个
+// O(n) 全表扫描换了个地方重新出现。SessionGC 现在只缓存 maxLifetime，
+// 堆的大小不应该随调用次数增长。
+func TestSessionGCDoesNotRescanSessionsOnEveryCall(t *testing.T) {
+	raw, err := newProvider(nil)
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+	p := raw.(*Provider)
+	defer p.Close()
+
+	p.SessionGC(3600)
+	for i := 0; i < 50; i++ {
+		if _, err := p.SessionInit(string(rune('a' + i%26))); err != nil {
+			t.Fatalf("SessionInit ERR: %v", err)
+		}
+	}
+
+	p.lock.Lock()
+	before := len(p.heap)
+	p.lock.Unlock()
+
+	for i := 0; i < 10; i++ {
+		p.SessionGC(3600)
+	}
+
+	p.lock.Lock()
+	after := len(p.heap)
+	p.lock.Unlock()
+
+	if after != before {
+		t.Fatalf("heap grew from %d to %d entries across repeated SessionGC calls with no new sessions", before, after)
+	}
+}
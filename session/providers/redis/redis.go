@@ -0,0 +1,303 @@
+// Package redis 是 session.Provider 的 redis 实现，会话以 gob 编码存成一个
+// redis 字符串键，靠 EXPIRE 做过期，天然支持多个 passport 实例共享同一份会话。
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+
+	"github.com/liuhengloveyou/passport/session"
+)
+
+func init() {
+	session.RegisterProvider("redis", newProvider)
+}
+
+const defaultMaxAge = 3600 // 秒，首次 SessionInit 时使用，之后由 SessionGC(maxLifetime) 刷新
+
+// Config 对应 SessionManager.StoreConfig。
+type Config struct {
+	Addr     string `json:"addr"`      // redis 地址，如 127.0.0.1:6379
+	Password string `json:"password"`  // AUTH 密码，空表示不需要
+	DbNum    int    `json:"db_num"`    // SELECT 的库号
+	PoolSize int    `json:"pool_size"` // 连接池大小
+}
+
+type payload struct {
+	CreateTime int64
+	Active     int64
+	Values     map[interface{}]interface{}
+}
+
+type sessionStore struct {
+	sid      string
+	payload  payload
+	provider *Provider
+	lock     sync.RWMutex
+}
+
+func (s *sessionStore) Id(sid string) string {
+	if sid != "" {
+		s.sid = sid
+	}
+
+	return s.sid
+}
+
+func (s *sessionStore) CreateTime() int64 {
+	return s.payload.CreateTime
+}
+
+func (s *sessionStore) Active(set bool) int64 {
+	if set {
+		s.lock.Lock()
+		s.payload.Active = time.Now().Unix()
+		s.saveLocked()
+		s.lock.Unlock()
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Active
+}
+
+func (s *sessionStore) Keys() []interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]interface{}, 0, len(s.payload.Values))
+	for k := range s.payload.Values {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func (s *sessionStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.payload.Values[key]
+}
+
+func (s *sessionStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.payload.Values[key] = val
+	return s.saveLocked()
+}
+
+func (s *sessionStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.payload.Values, key)
+	return s.saveLocked()
+}
+
+func (s *sessionStore) Release() {
+}
+
+// saveLocked 把当前内容 SET 回 redis，并用 EXPIRE 续上空闲超时，调用者需持有 s.lock。
+func (s *sessionStore) saveLocked() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.payload); err != nil {
+		return err
+	}
+
+	conn := s.provider.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", s.sid, buf.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := conn.Do("EXPIRE", s.sid, s.provider.maxAge())
+	return err
+}
+
+type Provider struct {
+	pool      *redigo.Pool
+	maxAgeVal int64
+	lock      sync.RWMutex
+}
+
+func newProvider(config interface{}) (session.Provider, error) {
+	conf := &Config{}
+
+	if config != nil {
+		b, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Addr == "" {
+		conf.Addr = "127.0.0.1:6379"
+	}
+	if conf.PoolSize <= 0 {
+		conf.PoolSize = 10
+	}
+
+	pool := &redigo.Pool{
+		MaxIdle:     conf.PoolSize,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redigo.Conn, error) {
+			c, err := redigo.Dial("tcp", conf.Addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if conf.Password != "" {
+				if _, err := c.Do("AUTH", conf.Password); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+
+			if _, err := c.Do("SELECT", conf.DbNum); err != nil {
+				c.Close()
+				return nil, err
+			}
+
+			return c, nil
+		},
+	}
+
+	return &Provider{pool: pool, maxAgeVal: defaultMaxAge}, nil
+}
+
+func (p *Provider) maxAge() int64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.maxAgeVal
+}
+
+func (p *Provider) load(sid string) (*sessionStore, error) {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	b, err := redigo.Bytes(conn.Do("GET", sid))
+	if err != nil {
+		return nil, err
+	}
+
+	store := &sessionStore{sid: sid, provider: p}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&store.payload); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionInit(sid string) (session.SessionStore, error) {
+	now := time.Now().Unix()
+
+	store := &sessionStore{
+		sid:      sid,
+		provider: p,
+		payload: payload{
+			CreateTime: now,
+			Active:     now,
+			Values:     make(map[interface{}]interface{}),
+		},
+	}
+
+	store.lock.Lock()
+	err := store.saveLocked()
+	store.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *Provider) SessionRead(sid string) (session.SessionStore, error) {
+	store, err := p.load(sid)
+	if err == redigo.ErrNil {
+		return p.SessionInit(sid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 每次读取都滑动一次过期时间
+	conn := p.pool.Get()
+	conn.Do("EXPIRE", sid, p.maxAge())
+	conn.Close()
+
+	return store, nil
+}
+
+func (p *Provider) SessionExist(sid string) (bool, error) {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	return redigo.Bool(conn.Do("EXISTS", sid))
+}
+
+func (p *Provider) SessionRegenerate(oldsid, sid string) (session.SessionStore, error) {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	exist, err := redigo.Bool(conn.Do("EXISTS", oldsid))
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return p.SessionInit(sid)
+	}
+
+	if _, err := conn.Do("RENAME", oldsid, sid); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Do("EXPIRE", sid, p.maxAge()); err != nil {
+		return nil, err
+	}
+
+	return p.load(sid)
+}
+
+func (p *Provider) SessionDestroy(sid string) error {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", sid)
+	return err
+}
+
+func (p *Provider) SessionAll() int {
+	conn := p.pool.Get()
+	defer conn.Close()
+
+	n, err := redigo.Int(conn.Do("DBSIZE"))
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// SessionGC 对 redis 实现来说是个提示：会话本身靠 EXPIRE 自动过期，
+// 这里只记录 maxLifetime，供之后的 SET/EXPIRE 续期使用。
+func (p *Provider) SessionGC(maxLifetime int64) {
+	if maxLifetime <= 0 {
+		return
+	}
+
+	p.lock.Lock()
+	p.maxAgeVal = maxLifetime
+	p.lock.Unlock()
+}
@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/liuhengloveyou/passport/session/sessiontest"
+)
+
+func TestRedisProvider(t *testing.T) {
+	const addr = "127.0.0.1:6379"
+
+	conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+	if err != nil {
+		t.Skipf("no redis reachable at %s, skipping: %v", addr, err)
+	}
+	conn.Close()
+
+	p, err := newProvider(&Config{Addr: addr})
+	if err != nil {
+		t.Fatalf("newProvider ERR: %v", err)
+	}
+
+	sessiontest.RunProviderSuite(t, p)
+}
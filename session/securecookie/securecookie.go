@@ -0,0 +1,194 @@
+// Package securecookie 实现一套 securecookie 风格的编解码链：
+// gob 序列化 -> AES-CTR 加密（可选）-> HMAC-SHA256 签名 -> base64url 编码，
+// 并在值里带上时间戳用于校验 MaxAge。支持按顺序尝试多个 key pair 实现密钥轮换：
+// 编码永远用第一个 Codec，解码则依次尝试直到有一个验签通过。
+package securecookie
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMaxAge = 86400 * 30 // 30 天
+
+// now 可在测试里替换，校验时间戳时使用。
+var now = func() int64 { return time.Now().Unix() }
+
+// Codec 持有一对签名/加密密钥。hashKey 必填，blockKey 留空时只签名不加密。
+type Codec struct {
+	hashKey  []byte
+	blockKey []byte
+	maxAge   int64
+}
+
+// New 用一对 (hashKey, blockKey) 构造一个 Codec，blockKey 可以传 nil 表示不加密。
+func New(hashKey, blockKey []byte) *Codec {
+	return &Codec{
+		hashKey:  hashKey,
+		blockKey: blockKey,
+		maxAge:   defaultMaxAge,
+	}
+}
+
+// MaxAge 设置该 Codec 解码时允许的最大存活秒数，0 表示不校验。
+func (c *Codec) MaxAge(age int64) *Codec {
+	c.maxAge = age
+	return c
+}
+
+// Encode 用单个 Codec 编码一个值，等价于 EncodeMulti(name, value, c)。
+func Encode(name string, value interface{}, c *Codec) (string, error) {
+	return EncodeMulti(name, value, c)
+}
+
+// Decode 用单个 Codec 解码一个值，等价于 DecodeMulti(name, str, dst, c)。
+func Decode(name, str string, dst interface{}, c *Codec) error {
+	return DecodeMulti(name, str, dst, c)
+}
+
+// EncodeMulti 总是用 codecs 里的第一个 Codec 编码，后面的 codec 仅用于轮换时解码旧值。
+func EncodeMulti(name string, value interface{}, codecs ...*Codec) (string, error) {
+	if len(codecs) == 0 {
+		return "", errors.New("securecookie: no codecs provided")
+	}
+
+	return codecs[0].encode(name, value)
+}
+
+// DecodeMulti 依次用每个 Codec 尝试解码，第一个验签通过的结果即为最终结果，
+// 这样只要 str 是用轮换中的任意一对旧密钥签出来的，都能正确解出。
+func DecodeMulti(name, str string, dst interface{}, codecs ...*Codec) error {
+	if len(codecs) == 0 {
+		return errors.New("securecookie: no codecs provided")
+	}
+
+	var lastErr error
+	for _, c := range codecs {
+		if err := c.decode(name, str, dst); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+func (c *Codec) encode(name string, value interface{}) (string, error) {
+	if len(c.hashKey) == 0 {
+		return "", errors.New("securecookie: hash key is not set")
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return "", err
+	}
+	b := buf.Bytes()
+
+	if len(c.blockKey) > 0 {
+		var err error
+		if b, err = encrypt(c.blockKey, b); err != nil {
+			return "", err
+		}
+	}
+
+	b64 := base64.URLEncoding.EncodeToString(b)
+	ts := strconv.FormatInt(now(), 10)
+	mac := c.computeMac(name, ts, b64)
+
+	return strings.Join([]string{ts, b64, base64.URLEncoding.EncodeToString(mac)}, "|"), nil
+}
+
+func (c *Codec) decode(name, str string, dst interface{}) error {
+	if len(c.hashKey) == 0 {
+		return errors.New("securecookie: hash key is not set")
+	}
+
+	parts := strings.Split(str, "|")
+	if len(parts) != 3 {
+		return errors.New("securecookie: invalid value format")
+	}
+	ts, b64, macB64 := parts[0], parts[1], parts[2]
+
+	if c.maxAge > 0 {
+		tsInt, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return errors.New("securecookie: invalid timestamp")
+		}
+		if now()-tsInt > c.maxAge {
+			return errors.New("securecookie: expired timestamp")
+		}
+	}
+
+	mac, err := base64.URLEncoding.DecodeString(macB64)
+	if err != nil {
+		return errors.New("securecookie: invalid mac encoding")
+	}
+	if !hmac.Equal(mac, c.computeMac(name, ts, b64)) {
+		return errors.New("securecookie: the value is not valid")
+	}
+
+	b, err := base64.URLEncoding.DecodeString(b64)
+	if err != nil {
+		return errors.New("securecookie: invalid value encoding")
+	}
+
+	if len(c.blockKey) > 0 {
+		if b, err = decrypt(c.blockKey, b); err != nil {
+			return err
+		}
+	}
+
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(dst)
+}
+
+func (c *Codec) computeMac(parts ...string) []byte {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(strings.Join(parts, "|")))
+	return mac.Sum(nil)
+}
+
+func encrypt(blockKey, value []byte) ([]byte, error) {
+	block, err := aes.NewCipher(blockKey)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, len(value))
+	cipher.NewCTR(block, iv).XORKeyStream(out, value)
+
+	return append(iv, out...), nil
+}
+
+func decrypt(blockKey, value []byte) ([]byte, error) {
+	block, err := aes.NewCipher(blockKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) < aes.BlockSize {
+		return nil, errors.New("securecookie: ciphertext too short")
+	}
+	iv, ciphertext := value[:aes.BlockSize], value[aes.BlockSize:]
+
+	out := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(out, ciphertext)
+
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package securecookie
+
+import "testing"
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		blockKey []byte
+	}{
+		{name: "signed-only", blockKey: nil},
+		{name: "signed-and-encrypted", blockKey: []byte("0123456789abcdef")},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			codec := New([]byte("hash-key-0123456789"), c.blockKey)
+
+			encoded, err := EncodeMulti("session", map[string]string{"k": "v"}, codec)
+			if err != nil {
+				t.Fatalf("EncodeMulti ERR: %v", err)
+			}
+
+			var got map[string]string
+			if err := DecodeMulti("session", encoded, &got, codec); err != nil {
+				t.Fatalf("DecodeMulti ERR: %v", err)
+			}
+			if got["k"] != "v" {
+				t.Fatalf("got = %v, want k=v", got)
+			}
+		})
+	}
+}
+
+func TestDecodeMultiKeyRotation(t *testing.T) {
+	oldCodec := New([]byte("old-hash-key"), []byte("old-block-key-16"))
+	newCodec := New([]byte("new-hash-key"), []byte("new-block-key-16"))
+
+	encoded, err := EncodeMulti("session", "payload", oldCodec)
+	if err != nil {
+		t.Fatalf("EncodeMulti ERR: %v", err)
+	}
+
+	var got string
+	if err := DecodeMulti("session", encoded, &got, newCodec, oldCodec); err != nil {
+		t.Fatalf("DecodeMulti with rotated keys ERR: %v", err)
+	}
+	if got != "payload" {
+		t.Fatalf("got = %q, want payload", got)
+	}
+}
+
+func TestDecodeRejectsTamperedValue(t *testing.T) {
+	codec := New([]byte("hash-key"), nil)
+
+	encoded, err := EncodeMulti("session", "payload", codec)
+	if err != nil {
+		t.Fatalf("EncodeMulti ERR: %v", err)
+	}
+
+	tampered := encoded[:len(encoded)-1] + "x"
+
+	var got string
+	if err := DecodeMulti("session", tampered, &got, codec); err == nil {
+		t.Fatalf("DecodeMulti accepted a tampered value")
+	}
+}
+
+func TestDecodeRejectsExpiredValue(t *testing.T) {
+	codec := New([]byte("hash-key"), nil).MaxAge(1)
+
+	restore := now
+	now = func() int64 { return 1000 }
+	encoded, err := EncodeMulti("session", "payload", codec)
+	now = restore
+	if err != nil {
+		t.Fatalf("EncodeMulti ERR: %v", err)
+	}
+
+	restore = now
+	now = func() int64 { return 1002 }
+	defer func() { now = restore }()
+
+	var got string
+	if err := DecodeMulti("session", encoded, &got, codec); err == nil {
+		t.Fatalf("DecodeMulti accepted an expired value")
+	}
+}
@@ -1,7 +1,6 @@
 package session
 
 import (
-	"container/list"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -17,11 +16,8 @@ import (
 
 var (
 	defaultSessionManager *SessionManager = nil
-
-	stores = make(map[string]SessionStoreType)
 )
 
-type SessionStoreType func(interface{}) (SessionStore, error)
 type PrepireReleaseFunc func(SessionStore) // 会话销毁时回调的函数
 
 type SessionStore interface {
@@ -35,7 +31,14 @@ type SessionStore interface {
 	Release()                        // 销毁该会话
 }
 
-////
+// WriterBinder 是可选接口，像 cookie store 这种把会话内容整体存在 cookie 值里的
+// SessionStore，每次内容变化都要重新下发 Set-Cookie。实现了该接口的 SessionStore
+// 会在每次 GetSession 时拿到当前请求的 http.ResponseWriter 和一份 cookie 模板。
+type WriterBinder interface {
+	BindWriter(w http.ResponseWriter, cookie *http.Cookie)
+}
+
+// //
 type SessionManager struct {
 	Domain       string      `json:"domain"`
 	StoreType    string      `json:"store_type"`
@@ -44,33 +47,12 @@ type SessionManager struct {
 	CookieExpire int         `json:"cookie_expire"`
 	StoreConfig  interface{} `json:"store_config"`
 
-	prepireRelease PrepireReleaseFunc       // 会话过期时的回调
-	sessions       map[string]*list.Element // 本系统所有管理的会话
-	list           *list.List
+	prepireRelease PrepireReleaseFunc // 会话过期时的回调
+	provider       Provider           // 会话后端存储，由 StoreType 决定具体实现
 	lock           sync.RWMutex
 	destroied      bool
 }
 
-func RegisterSessionStore(name string, one SessionStoreType) {
-	if one == nil {
-		panic("Register SessionStore nil")
-	}
-
-	if _, dup := stores[name]; dup {
-		panic("Register SessionStore duplicate for " + name)
-	}
-
-	stores[name] = one
-}
-
-func newSessionStore(typeName string, config interface{}) (SessionStore, error) {
-	if newFunc, ok := stores[typeName]; ok {
-		return newFunc(config)
-	}
-
-	return nil, fmt.Errorf("No SessionManager types " + typeName)
-}
-
 func NewSessionManager(sessionConfig interface{}) (m *SessionManager) {
 	if sessionConfig == nil {
 		return nil
@@ -91,8 +73,11 @@ func NewSessionManager(sessionConfig interface{}) (m *SessionManager) {
 
 	}
 
-	m.sessions = make(map[string]*list.Element)
-	m.list = list.New()
+	m.provider, err = newProvider(m.StoreType, m.StoreConfig)
+	if err != nil {
+		panic(err)
+	}
+
 	m.gc()
 
 	return m
@@ -104,43 +89,47 @@ func (p *SessionManager) GetSession(w http.ResponseWriter, r *http.Request, sess
 
 	cookie, errs := r.Cookie(p.CookieName)
 	if errs != nil || cookie.Value == "" {
-		if sessionid == "" {
-			sid, err = p.sessionId()
-		} else {
-			sid = sessionid
-		}
+		sid, err = p.newSid(sessionid)
 		writeCookie = true
 	} else {
 		sid, err = url.QueryUnescape(cookie.Value)
+		if err == nil && !p.validSid(sid) {
+			// cookie 被改成了不合法的格式（比如企图借 sid 做路径穿越），当成没带
+			// cookie 处理，不能把它原样传给 provider——file provider 会拿它拼文件路径。
+			sid, err = p.newSid(sessionid)
+			writeCookie = true
+		}
 	}
 	if err != nil {
 		return
 	}
 
-	if sess, ok := p.sessions[sid]; ok {
-		session = sess.Value.(SessionStore)
-		session.Active(true)
-		p.lock.Lock()
-		p.list.MoveToBack(sess)
-		p.lock.Unlock()
-		return
-	}
-
-	// 新会话
-	session, err = newSessionStore(p.StoreType, p.StoreConfig)
+	exist, err := p.provider.SessionExist(sid)
 	if err != nil {
 		return
 	}
-	session.Id(sid)
 
-	p.lock.Lock()
-	p.sessions[sid] = p.list.PushBack(session)
-	p.lock.Unlock()
+	if exist {
+		session, err = p.provider.SessionRead(sid)
+		if err != nil {
+			return
+		}
+		session.Active(true)
+	} else {
+		// 新会话
+		session, err = p.provider.SessionInit(sid)
+		if err != nil {
+			return
+		}
+	}
 
 	if writeCookie == true {
+		// 同 SessionRegenerateId：要用 session.Id("") 回读出的真实 sid，不能用上面
+		// 传进去的 sid——cookie store 的 SessionInit 会无视传入值，自己算一个编码
+		// 后的 sid 出来，写错了浏览器带回来的 cookie 就解不出来，每次都是新会话。
 		cookie = &http.Cookie{
 			Name:   p.CookieName,
-			Value:  url.QueryEscape(sid),
+			Value:  url.QueryEscape(session.Id("")),
 			Path:   "/",
 			Domain: p.Domain,
 		}
@@ -152,6 +141,64 @@ func (p *SessionManager) GetSession(w http.ResponseWriter, r *http.Request, sess
 		http.SetCookie(w, cookie)
 	}
 
+	if binder, ok := session.(WriterBinder); ok {
+		template := &http.Cookie{
+			Name:   p.CookieName,
+			Path:   "/",
+			Domain: p.Domain,
+		}
+
+		if p.CookieExpire >= 0 {
+			template.MaxAge = p.CookieExpire
+		}
+
+		binder.BindWriter(w, template)
+	}
+
+	r.AddCookie(cookie)
+
+	return
+}
+
+// SessionRegenerateId 在用户权限发生变化（典型如登录成功）后调用，换一个新的 sid，
+// 把旧会话的键值对搬到新会话上，再重写 cookie，避免会话固定攻击——否则攻击者可以预先
+// 诱导受害者使用一个已知的 sid，待其登录后直接拿这个 sid 冒充。
+func (p *SessionManager) SessionRegenerateId(w http.ResponseWriter, r *http.Request) (session SessionStore, err error) {
+	oldsid := ""
+	if cookie, errc := r.Cookie(p.CookieName); errc == nil && cookie.Value != "" {
+		if unescaped, errq := url.QueryUnescape(cookie.Value); errq == nil && p.validSid(unescaped) {
+			oldsid = unescaped
+		}
+	}
+
+	sid, err := p.sessionId()
+	if err != nil {
+		return
+	}
+
+	session, err = p.provider.SessionRegenerate(oldsid, sid)
+	if err != nil {
+		return
+	}
+
+	// 注意这里要用 session.Id("") 回读出的真实 sid 而不是上面传进去的 sid：
+	// 对 cookie store 来说 sid 本身就是编码后的载荷，SessionRegenerate 可能
+	// 返回一个跟传入值不同的字符串，写错了浏览器下次带回来的 cookie 就解不出来。
+	cookie := &http.Cookie{
+		Name:   p.CookieName,
+		Value:  url.QueryEscape(session.Id("")),
+		Path:   "/",
+		Domain: p.Domain,
+	}
+	if p.CookieExpire >= 0 {
+		cookie.MaxAge = p.CookieExpire
+	}
+	http.SetCookie(w, cookie)
+
+	if binder, ok := session.(WriterBinder); ok {
+		binder.BindWriter(w, cookie)
+	}
+
 	r.AddCookie(cookie)
 
 	return
@@ -165,8 +212,10 @@ func (p *SessionManager) SessionDestroy(w http.ResponseWriter, r *http.Request)
 
 	sessionid, _ = url.QueryUnescape(cookie.Value)
 
-	if session, ok := p.sessions[sessionid]; ok {
-		session.Value.(SessionStore).Release()
+	if p.validSid(sessionid) {
+		if err := p.provider.SessionDestroy(sessionid); err != nil {
+			log.Errorln("p.provider.SessionDestroy ERR: ", err)
+		}
 	}
 
 	http.SetCookie(w, &http.Cookie{
@@ -183,56 +232,68 @@ func (p *SessionManager) GetSessionById(sid string) (session SessionStore, err e
 	if sid == "" {
 		return nil, nil
 	}
-
-	if sess, ok := p.sessions[sid]; ok {
-		session = sess.Value.(SessionStore)
-		session.Active(true)
-		p.lock.Lock()
-		p.list.MoveToBack(sess)
-		p.lock.Unlock()
-		return
+	if !p.validSid(sid) {
+		return nil, fmt.Errorf("session: invalid sid")
 	}
 
-	// 新会话
-	session, err = newSessionStore(p.StoreType, p.StoreConfig)
+	exist, err := p.provider.SessionExist(sid)
 	if err != nil {
 		return
 	}
-	session.Id(sid)
 
-	p.lock.Lock()
-	p.sessions[sid] = p.list.PushBack(session)
-	p.lock.Unlock()
+	if exist {
+		session, err = p.provider.SessionRead(sid)
+		if err != nil {
+			return
+		}
+		session.Active(true)
+		return
+	}
 
+	// 新会话
+	session, err = p.provider.SessionInit(sid)
 	return
 }
 
 func (p *SessionManager) SessionDestroyById(sid string) {
-	if session, ok := p.sessions[sid]; ok {
-		session.Value.(SessionStore).Release()
+	if !p.validSid(sid) {
+		return
+	}
+
+	if err := p.provider.SessionDestroy(sid); err != nil {
+		log.Errorln("p.provider.SessionDestroy ERR: ", err)
 	}
 }
 
 func (p *SessionManager) SessionUpdate(sid string) {
-	if sess, ok := p.sessions[sid]; ok {
-		sess.Value.(SessionStore).Active(true)
-		p.lock.Lock()
-		p.list.MoveToBack(sess)
-		p.lock.Unlock()
+	if !p.validSid(sid) {
 		return
 	}
+
+	session, err := p.provider.SessionRead(sid)
+	if err != nil {
+		return
+	}
+
+	session.Active(true)
 }
 
 func (p *SessionManager) Destroy() {
-	p.sessions = nil
-	p.list = nil
 	p.destroied = true
+
+	if closer, ok := p.provider.(ProviderCloser); ok {
+		closer.Close()
+	}
 }
 
 func (p *SessionManager) SetPrepireRelease(pf PrepireReleaseFunc) {
 	p.lock.Lock()
 	p.prepireRelease = pf
 	p.lock.Unlock()
+
+	if setter, ok := p.provider.(PrepireReleaseSetter); ok {
+		setter.SetPrepireRelease(pf)
+	}
 }
 
 func (p *SessionManager) sessionId() (string, error) {
@@ -245,44 +306,56 @@ func (p *SessionManager) sessionId() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
-func (p *SessionManager) gc() {
-	var sleep int64 = 10
+// newSid 返回调用方指定的 sessionid，没指定就现铸一个新的——GetSession 在
+// "没有可用的 sid" 这一种情况下（没带 cookie，或者带的 cookie 格式不对）都要
+// 走同一条路径。
+func (p *SessionManager) newSid(sessionid string) (string, error) {
+	if sessionid != "" {
+		return sessionid, nil
+	}
 
-	for p.destroied == false {
-		var element *list.Element
+	return p.sessionId()
+}
 
-		p.lock.RLock()
-		if element = p.list.Front(); element == nil {
-			p.lock.RUnlock()
-			break
-		}
+// sidLen 是 sessionId() 产出的十六进制字符串长度（24 字节 -> 48 个十六进制字符）。
+const sidLen = 48
+
+// validSid 校验一个来路不可信的 sid（典型地来自客户端 Cookie）是否是合法格式，
+// 在它被传给 provider、拼成文件路径/缓存 key 之前拦下来——否则一个解码后变成
+// "../../etc/passwd" 之类的 cookie 值就能让 file provider 读写/删除任意文件。
+// Provider 如果不是按 sessionId() 的格式来生成/消费 sid（比如 cookie store 把
+// sid 本身当成编码后的会话内容），应该实现 SidValidator 提供自己的校验规则。
+func (p *SessionManager) validSid(sid string) bool {
+	if validator, ok := p.provider.(SidValidator); ok {
+		return validator.ValidSid(sid)
+	}
 
-		if (element.Value.(SessionStore).Active(false) + p.IdleTime) > time.Now().Unix() {
-			sleep = (element.Value.(SessionStore).Active(false) + int64(p.IdleTime)) - time.Now().Unix()
-			p.lock.RUnlock()
-			break
-		}
-		p.lock.RUnlock()
+	if len(sid) != sidLen {
+		return false
+	}
 
-		log.Warningln("sessionrelease:", element.Value.(SessionStore).Id(""))
-		p.lock.Lock()
-		if p.prepireRelease != nil {
-			p.prepireRelease(element.Value.(SessionStore))
+	for _, c := range sid {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
 		}
-		element.Value.(SessionStore).Release()
-		delete(p.sessions, element.Value.(SessionStore).Id(""))
-		p.list.Remove(element)
-		p.lock.Unlock()
 	}
 
-	if p.destroied == false {
-		time.AfterFunc(time.Duration(sleep)*time.Second, p.gc)
+	return true
+}
+
+func (p *SessionManager) gc() {
+	if p.destroied == true {
+		return
 	}
+
+	p.provider.SessionGC(p.IdleTime)
+
+	time.AfterFunc(time.Duration(p.IdleTime)*time.Second, p.gc)
 }
 
-////////////////////////////////////////////////////////////////////////////////
+// //////////////////////////////////////////////////////////////////////////////
 // 公开接口
-////////////////////////////////////////////////////////////////////////////////
+// //////////////////////////////////////////////////////////////////////////////
 func InitDefaultSessionManager(conf interface{}) *SessionManager {
 	if defaultSessionManager != nil {
 		defaultSessionManager.Destroy()
@@ -304,6 +377,10 @@ func SessionDestroy(w http.ResponseWriter, r *http.Request) (sid string) {
 	return defaultSessionManager.SessionDestroy(w, r)
 }
 
+func SessionRegenerateId(w http.ResponseWriter, r *http.Request) (session SessionStore, err error) {
+	return defaultSessionManager.SessionRegenerateId(w, r)
+}
+
 func SessionDestroyById(sid string) {
 	defaultSessionManager.SessionDestroyById(sid)
 }
@@ -0,0 +1,122 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liuhengloveyou/passport/session"
+	_ "github.com/liuhengloveyou/passport/session/providers/file"
+	_ "github.com/liuhengloveyou/passport/session/providers/memory"
+)
+
+func newTestManager(t *testing.T) *session.SessionManager {
+	t.Helper()
+
+	m := session.NewSessionManager(map[string]interface{}{
+		"store_type":  "memory",
+		"cookie_name": "test_sid",
+		"idle_time":   3600,
+	})
+	if m == nil {
+		t.Fatal("NewSessionManager returned nil")
+	}
+
+	return m
+}
+
+func TestSessionRegenerateIdPreservesValuesAndChangesSid(t *testing.T) {
+	m := newTestManager(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	sess, err := m.GetSession(w, r, "")
+	if err != nil {
+		t.Fatalf("GetSession ERR: %v", err)
+	}
+	if err := sess.Set("uid", 42); err != nil {
+		t.Fatalf("Set ERR: %v", err)
+	}
+	oldsid := sess.Id("")
+
+	// 模拟浏览器把 Set-Cookie 带回下一个请求
+	r2 := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r2.AddCookie(c)
+	}
+
+	w2 := httptest.NewRecorder()
+	newSess, err := m.SessionRegenerateId(w2, r2)
+	if err != nil {
+		t.Fatalf("SessionRegenerateId ERR: %v", err)
+	}
+
+	if newSess.Id("") == oldsid {
+		t.Fatalf("SessionRegenerateId did not change the sid")
+	}
+	if got := newSess.Get("uid"); got != 42 {
+		t.Fatalf("Get(uid) = %v, want 42", got)
+	}
+
+	found := false
+	for _, c := range w2.Result().Cookies() {
+		if c.Name == "test_sid" {
+			found = true
+			if c.Value == "" {
+				t.Fatalf("rewritten cookie has an empty value")
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("SessionRegenerateId did not rewrite the session cookie")
+	}
+}
+
+// 回归测试：一个携带路径穿越字符串的伪造 cookie 不应该原样传给 file
+// provider——否则它会被直接拼进 ioutil.ReadFile/WriteFile/os.Remove 的路径里。
+func TestGetSessionRejectsPathTraversalCookie(t *testing.T) {
+	savePath := t.TempDir()
+
+	// 在 savePath 之外放一个文件，模拟攻击者想读到的目标。
+	outsideDir := t.TempDir()
+	planted := filepath.Join(outsideDir, "secret.txt")
+	if err := os.WriteFile(planted, []byte("top secret"), 0600); err != nil {
+		t.Fatalf("os.WriteFile ERR: %v", err)
+	}
+
+	m := session.NewSessionManager(map[string]interface{}{
+		"store_type":  "file",
+		"cookie_name": "test_sid",
+		"idle_time":   3600,
+		"store_config": map[string]interface{}{
+			"save_path": savePath,
+		},
+	})
+	if m == nil {
+		t.Fatal("NewSessionManager returned nil")
+	}
+
+	maliciousSid := "../../../../" + filepath.Base(outsideDir) + "/secret"
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "test_sid", Value: url.QueryEscape(maliciousSid)})
+
+	sess, err := m.GetSession(w, r, "")
+	if err != nil {
+		t.Fatalf("GetSession ERR: %v", err)
+	}
+
+	if sess.Id("") == maliciousSid {
+		t.Fatalf("GetSession accepted a path-traversal sid instead of minting a fresh one")
+	}
+
+	// planted 文件必须原封不动，没有被当作会话文件读写/删除过。
+	if b, err := os.ReadFile(planted); err != nil || string(b) != "top secret" {
+		t.Fatalf("planted file outside save_path was touched: %v, %q", err, b)
+	}
+}
@@ -0,0 +1,66 @@
+// Package sessiontest 提供一套公共的 session.Provider 行为测试套件，
+// 好让 memory/file/redis 等每个实现都跑同一组用例，保证语义一致。
+package sessiontest
+
+import (
+	"testing"
+
+	"github.com/liuhengloveyou/passport/session"
+)
+
+// RunProviderSuite 针对给定的 Provider 跑一遍 SessionInit/Read/Exist/Regenerate/Destroy 的基本语义。
+func RunProviderSuite(t *testing.T, p session.Provider) {
+	t.Helper()
+
+	const sid = "0123456789abcdef0123456789abcdef"
+
+	exist, err := p.SessionExist(sid)
+	if err != nil {
+		t.Fatalf("SessionExist ERR: %v", err)
+	}
+	if exist {
+		t.Fatalf("SessionExist(%s) = true before init", sid)
+	}
+
+	store, err := p.SessionInit(sid)
+	if err != nil {
+		t.Fatalf("SessionInit ERR: %v", err)
+	}
+
+	if err := store.Set("k", "v"); err != nil {
+		t.Fatalf("Set ERR: %v", err)
+	}
+
+	exist, err = p.SessionExist(sid)
+	if err != nil || !exist {
+		t.Fatalf("SessionExist(%s) = %v, %v after init", sid, exist, err)
+	}
+
+	read, err := p.SessionRead(sid)
+	if err != nil {
+		t.Fatalf("SessionRead ERR: %v", err)
+	}
+	if got := read.Get("k"); got != "v" {
+		t.Fatalf("Get(k) = %v, want v", got)
+	}
+
+	const newsid = "fedcba9876543210fedcba9876543210"
+	regen, err := p.SessionRegenerate(sid, newsid)
+	if err != nil {
+		t.Fatalf("SessionRegenerate ERR: %v", err)
+	}
+	if got := regen.Get("k"); got != "v" {
+		t.Fatalf("after regenerate, Get(k) = %v, want v", got)
+	}
+
+	if exist, _ = p.SessionExist(sid); exist {
+		t.Fatalf("old sid %s still exists after regenerate", sid)
+	}
+
+	if err := p.SessionDestroy(newsid); err != nil {
+		t.Fatalf("SessionDestroy ERR: %v", err)
+	}
+	if exist, _ = p.SessionExist(newsid); exist {
+		t.Fatalf("sid %s still exists after destroy", newsid)
+	}
+}
@@ -0,0 +1,53 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// 回归测试：validSid 必须在 sid 被传给 provider 之前就拦下非法格式——之前
+// file provider 只在空/短 sid 这一种情况下做了保护，像 "../../etc/passwd"
+// 这样的字符串可以一路带进 ioutil.ReadFile/WriteFile/os.Remove。
+func TestValidSidRejectsNonHexAndWrongLength(t *testing.T) {
+	m := &SessionManager{}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand.Read ERR: %v", err)
+	}
+	validSid := hex.EncodeToString(b)
+
+	cases := []struct {
+		sid  string
+		want bool
+	}{
+		{validSid, true},
+		{"", false},
+		{"../../../../tmp/evil", false},
+		{"../002/secret.txt", false},
+		{validSid[:len(validSid)-1], false},       // 少一位
+		{validSid + "0", false},                   // 多一位
+		{validSid[:len(validSid)-1] + "G", false}, // 非十六进制字符
+	}
+
+	for _, c := range cases {
+		if got := m.validSid(c.sid); got != c.want {
+			t.Errorf("validSid(%q) = %v, want %v", c.sid, got, c.want)
+		}
+	}
+}
+
+// fakeAnySidProvider 模拟 cookie store 这种 sid 本身就是任意格式编码内容的
+// Provider，实现 SidValidator 之后 validSid 应该完全听它的。
+type fakeAnySidProvider struct{ Provider }
+
+func (fakeAnySidProvider) ValidSid(sid string) bool { return true }
+
+func TestValidSidDefersToProviderSidValidator(t *testing.T) {
+	m := &SessionManager{provider: fakeAnySidProvider{}}
+
+	if !m.validSid("../../../../tmp/evil") {
+		t.Fatalf("validSid should defer to the provider's SidValidator")
+	}
+}